@@ -0,0 +1,79 @@
+// This file is a hand-maintained stand-in for the protoc-gen-go output that
+// would normally be generated from proto/binlogdata.proto. It is NOT
+// generated and must not be treated as such: it exists only so that
+// vttablet/vstreamer has something to import, and it reproduces just the
+// VEventType values up to VERSION and the VEvent fields that package uses.
+//
+// The next time proto/binlogdata.proto changes and `make proto` is run,
+// this file should be deleted and replaced by the real protoc-gen-go
+// output, which will also carry the Marshal/Unmarshal and reflection
+// plumbing this stub deliberately omits.
+
+package binlogdata
+
+// VEventType enumerates the type of a VEvent sent over a VStream.
+//
+// The values from UNKNOWN through VERSION are reproduced here; of these,
+// this repository's vttablet/vstreamer package uses OTHER and HEARTBEAT.
+type VEventType int32
+
+const (
+	VEventType_UNKNOWN   VEventType = 0
+	VEventType_GTID      VEventType = 1
+	VEventType_BEGIN     VEventType = 2
+	VEventType_COMMIT    VEventType = 3
+	VEventType_ROLLBACK  VEventType = 4
+	VEventType_DDL       VEventType = 5
+	VEventType_INSERT    VEventType = 6
+	VEventType_REPLACE   VEventType = 7
+	VEventType_UPDATE    VEventType = 8
+	VEventType_DELETE    VEventType = 9
+	VEventType_SET       VEventType = 10
+	VEventType_OTHER     VEventType = 11
+	VEventType_ROW       VEventType = 12
+	VEventType_FIELD     VEventType = 13
+	VEventType_HEARTBEAT VEventType = 14
+	VEventType_VGTID     VEventType = 15
+	VEventType_JOURNAL   VEventType = 16
+	VEventType_VERSION   VEventType = 17
+)
+
+var vEventTypeName = map[VEventType]string{
+	VEventType_UNKNOWN:   "UNKNOWN",
+	VEventType_GTID:      "GTID",
+	VEventType_BEGIN:     "BEGIN",
+	VEventType_COMMIT:    "COMMIT",
+	VEventType_ROLLBACK:  "ROLLBACK",
+	VEventType_DDL:       "DDL",
+	VEventType_INSERT:    "INSERT",
+	VEventType_REPLACE:   "REPLACE",
+	VEventType_UPDATE:    "UPDATE",
+	VEventType_DELETE:    "DELETE",
+	VEventType_SET:       "SET",
+	VEventType_OTHER:     "OTHER",
+	VEventType_ROW:       "ROW",
+	VEventType_FIELD:     "FIELD",
+	VEventType_HEARTBEAT: "HEARTBEAT",
+	VEventType_VGTID:     "VGTID",
+	VEventType_JOURNAL:   "JOURNAL",
+	VEventType_VERSION:   "VERSION",
+}
+
+func (x VEventType) String() string {
+	if name, ok := vEventTypeName[x]; ok {
+		return name
+	}
+	return "UNKNOWN"
+}
+
+// VEvent is a message sent over a VStream to notify of an event on a shard.
+//
+// Only the fields consumed by this repository's vttablet/vstreamer package
+// are reproduced here; the full message also
+// carries a RowEvent, FieldEvent, Journal and other type-specific payloads.
+type VEvent struct {
+	Type      VEventType
+	Timestamp int64
+	Keyspace  string
+	Shard     string
+}
@@ -0,0 +1,268 @@
+/*
+Copyright 2019 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package heartbeat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/timer"
+	"vitess.io/vitess/go/vt/log"
+	"vitess.io/vitess/go/vt/logutil"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/topo"
+	"vitess.io/vitess/go/vt/vttablet/tabletserver/connpool"
+	"vitess.io/vitess/go/vt/vttablet/tabletserver/tabletenv"
+
+	querypb "vitess.io/vitess/go/vt/proto/query"
+)
+
+const sqlFetchMostRecentHeartbeat = "SELECT ts FROM %s.heartbeat WHERE keyspaceShard=%a"
+
+// HeartbeatSource is the read-side counterpart to HeartbeatSink: a place a
+// heartbeat tick can be read back from to compute replication lag. It is
+// symmetric with HeartbeatSink by construction, one HeartbeatSource per
+// HeartbeatSink that can be read back (the push-only http sink has none), so
+// lag can be computed from whichever backend a deployment trusts,
+// independent of the others.
+type HeartbeatSource interface {
+	// Name identifies the source for logging; it matches the HeartbeatSink
+	// of the same name.
+	Name() string
+	// Read returns the writer's clock, in nanoseconds, at the time of the
+	// most recently observed tick for keyspaceShard.
+	Read(ctx context.Context, keyspaceShard string) (ts int64, err error)
+}
+
+// newHeartbeatSources builds the sources configured for r, mirroring
+// newHeartbeatSinks, including the original MySQL source. Order does not
+// determine which source's reading is used: ComputeReplicationLag reads
+// every configured source and reports the freshest one. The http sink has
+// no corresponding source, since a webhook cannot be polled back; it is
+// silently skipped here.
+func newHeartbeatSources(r *Reader, config *tabletenv.TabletConfig) []HeartbeatSource {
+	sources := []HeartbeatSource{&mysqlHeartbeatSource{r: r}}
+	for _, name := range config.HeartbeatSinks {
+		switch name {
+		case mysqlSinkName:
+			// Always present; added above.
+		case "topo":
+			sources = append(sources, &topoHeartbeatSource{r: r})
+		case "http":
+			// http is a push-only sink; there is nothing to read back.
+		default:
+			log.Warningf("heartbeat: unknown sink %q, ignoring for lag computation", name)
+		}
+	}
+	return sources
+}
+
+// mysqlHeartbeatSource is the original source: it reads the tick back from
+// the _vt.heartbeat row via the reader's own connection pool.
+type mysqlHeartbeatSource struct {
+	r *Reader
+}
+
+func (s *mysqlHeartbeatSource) Name() string { return mysqlSinkName }
+
+func (s *mysqlHeartbeatSource) Read(ctx context.Context, keyspaceShard string) (int64, error) {
+	bindVars := map[string]*querypb.BindVariable{
+		"ks": sqltypes.StringBindVariable(keyspaceShard),
+	}
+	parsed := sqlparser.BuildParsedQuery(sqlFetchMostRecentHeartbeat, "_vt", ":ks")
+	query, err := parsed.GenerateQuery(bindVars, nil)
+	if err != nil {
+		return 0, err
+	}
+	conn, err := s.r.pool.Get(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Recycle()
+	res, err := conn.Exec(ctx, query, 1, true)
+	if err != nil {
+		return 0, err
+	}
+	if len(res.Rows) != 1 {
+		return 0, fmt.Errorf("heartbeat: expected 1 row for %s, got %d", keyspaceShard, len(res.Rows))
+	}
+	return res.Rows[0][0].ToInt64()
+}
+
+// topoHeartbeatSource reads the tick back from the topo server.
+type topoHeartbeatSource struct {
+	r *Reader
+}
+
+func (s *topoHeartbeatSource) Name() string { return "topo" }
+
+func (s *topoHeartbeatSource) Read(ctx context.Context, keyspaceShard string) (int64, error) {
+	conn, err := s.r.env.TopoServer().ConnForCell(ctx, topo.GlobalCell)
+	if err != nil {
+		return 0, err
+	}
+	path := fmt.Sprintf("heartbeat/%s", keyspaceShard)
+	data, _, err := conn.Get(ctx, path)
+	if err != nil {
+		return 0, err
+	}
+	var val topoHeartbeatValue
+	if err := json.Unmarshal(data, &val); err != nil {
+		return 0, err
+	}
+	return val.Ts, nil
+}
+
+// Reader periodically reads back the heartbeat written by a Writer and
+// computes the resulting lag, so the tablet's health reporter can use it as
+// a liveness/staleness signal independent of MySQL replication lag.
+//
+// When writer is non-nil, Reader feeds each computed lag sample into
+// writer.SetTargetLag, making Reader's own read loop (driven by Open) the
+// caller that actually exercises the AIMD interval controller: the health
+// reporter delegates its periodic replication-lag check to this Reader, so
+// this is where observed lag becomes available to feed back into the write
+// interval.
+type Reader struct {
+	env     tabletenv.Env
+	writer  *Writer
+	sources []HeartbeatSource
+
+	keyspaceShard string
+	interval      time.Duration
+	pool          *connpool.Pool
+
+	mu       sync.Mutex
+	isOpen   bool
+	ticks    *timer.Timer
+	errorLog *logutil.ThrottledLogger
+}
+
+// NewReader creates a new Reader. writer may be nil, in which case Reader
+// still computes and exposes lag but does not drive the AIMD controller.
+func NewReader(env tabletenv.Env, writer *Writer) *Reader {
+	config := env.Config()
+	if config.HeartbeatIntervalSeconds == 0 {
+		return &Reader{}
+	}
+	heartbeatInterval := time.Duration(config.HeartbeatIntervalSeconds * 1e9)
+	r := &Reader{
+		env:      env,
+		writer:   writer,
+		interval: heartbeatInterval,
+		pool: connpool.NewPool(env, "HeartbeatReadPool", tabletenv.ConnPoolConfig{
+			Size:               1,
+			IdleTimeoutSeconds: config.OltpReadPool.IdleTimeoutSeconds,
+		}),
+		ticks:    timer.NewTimer(heartbeatInterval),
+		errorLog: logutil.NewThrottledLogger("HeartbeatReader", 60*time.Second),
+	}
+	r.sources = newHeartbeatSources(r, config)
+	return r
+}
+
+// Open launches the ticker that periodically calls ComputeReplicationLag,
+// which is what turns writer.SetTargetLag from a reachable-but-uncalled
+// method into the tablet's actual adaptive-interval feedback loop. Open may
+// be called multiple times, as long as it was closed since last invocation.
+func (r *Reader) Open() {
+	if r.ticks == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.isOpen {
+		return
+	}
+	r.pool.Open(r.env.Config().DB.AppWithDB(), r.env.Config().DB.DbaWithDB(), r.env.Config().DB.AppDebugWithDB())
+	r.ticks.Start(func() {
+		if _, err := r.ComputeReplicationLag(context.Background(), time.Now()); err != nil {
+			r.errorLog.Errorf("heartbeat: failed to compute replication lag: %v", err)
+		}
+	})
+	r.isOpen = true
+}
+
+// Close stops the periodic ticker and closes the Reader's db connection. A
+// Reader can be re-opened after closing.
+func (r *Reader) Close() {
+	if r.ticks == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.isOpen {
+		return
+	}
+	r.ticks.Stop()
+	r.pool.Close()
+	r.isOpen = false
+}
+
+// InitKeyspaceShard records the keyspace/shard this Reader reads heartbeats
+// for. It must be called once, before the first ComputeReplicationLag, with
+// the same target the Writer on this tablet was initialized with (see
+// Writer.Init).
+func (r *Reader) InitKeyspaceShard(target querypb.Target) {
+	r.keyspaceShard = fmt.Sprintf("%s:%s", target.Keyspace, target.Shard)
+}
+
+// ComputeReplicationLag reads the tick back from every source r was
+// configured with and returns the freshest (lowest-lag) reading, so a
+// deployment that trusts a non-mysql source is actually measured from it:
+// if mysql is wedged behind a healthy vttablet still ticking its topo
+// sink, the topo source's lower lag wins, rather than lag silently
+// always being read back from mysql regardless of configuration. On
+// success, if r was constructed with a writer, the winning lag is also fed
+// into writer.SetTargetLag.
+func (r *Reader) ComputeReplicationLag(ctx context.Context, now time.Time) (time.Duration, error) {
+	if len(r.sources) == 0 {
+		return 0, errors.New("heartbeat: no source configured")
+	}
+	var (
+		best  time.Duration
+		found bool
+		first error
+	)
+	for _, source := range r.sources {
+		ts, err := source.Read(ctx, r.keyspaceShard)
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			continue
+		}
+		lag := now.Sub(time.Unix(0, ts))
+		if !found || lag < best {
+			best = lag
+			found = true
+		}
+	}
+	if !found {
+		return 0, first
+	}
+	if r.writer != nil {
+		r.writer.SetTargetLag(best)
+	}
+	return best, nil
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2019 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package heartbeat
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+var errTestSourceUnavailable = errors.New("heartbeat: test source unavailable")
+
+// fakeSource is a HeartbeatSource double that returns a fixed timestamp, or
+// a fixed error if err is set.
+type fakeSource struct {
+	name string
+	ts   int64
+	err  error
+}
+
+func (s *fakeSource) Name() string { return s.name }
+
+func (s *fakeSource) Read(ctx context.Context, keyspaceShard string) (int64, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	return s.ts, nil
+}
+
+func TestComputeReplicationLagFeedsSetTargetLag(t *testing.T) {
+	w := newAdaptiveWriter(300*time.Millisecond, 100*time.Millisecond, time.Second, 2*time.Second)
+	now := time.Unix(100, 0)
+	r := &Reader{
+		writer:  w,
+		sources: []HeartbeatSource{&fakeSource{name: mysqlSinkName, ts: now.Add(-5 * time.Second).UnixNano()}},
+	}
+
+	lag, err := r.ComputeReplicationLag(context.Background(), now)
+	if err != nil {
+		t.Fatalf("ComputeReplicationLag() returned error: %v", err)
+	}
+	if lag != 5*time.Second {
+		t.Fatalf("lag = %v, want 5s", lag)
+	}
+	// 5s lag is above lagThreshold (2s), so SetTargetLag must have ramped
+	// the writer's interval down, not left it untouched.
+	if w.interval >= 300*time.Millisecond {
+		t.Errorf("writer interval = %v, want decreased; ComputeReplicationLag must feed lag into SetTargetLag", w.interval)
+	}
+}
+
+// TestComputeReplicationLagUsesFreshestSource verifies that lag is not
+// always read from whichever source happens to be first: when mysql is
+// stale but a configured topo source is fresh, the topo reading must be the
+// one reported and the one that drives SetTargetLag.
+func TestComputeReplicationLagUsesFreshestSource(t *testing.T) {
+	w := newAdaptiveWriter(300*time.Millisecond, 100*time.Millisecond, time.Second, 2*time.Second)
+	now := time.Unix(1000, 0)
+	r := &Reader{
+		writer: w,
+		sources: []HeartbeatSource{
+			&fakeSource{name: mysqlSinkName, ts: now.Add(-30 * time.Second).UnixNano()}, // stale
+			&fakeSource{name: "topo", ts: now.Add(-1 * time.Second).UnixNano()},         // fresh
+		},
+	}
+
+	lag, err := r.ComputeReplicationLag(context.Background(), now)
+	if err != nil {
+		t.Fatalf("ComputeReplicationLag() returned error: %v", err)
+	}
+	if lag != time.Second {
+		t.Fatalf("lag = %v, want 1s from the fresher topo source, not 30s from mysql", lag)
+	}
+	// 1s lag is below lagThreshold (2s), so SetTargetLag must have backed
+	// the interval off, not ramped it down as the stale 30s mysql reading
+	// would have.
+	if w.interval <= 300*time.Millisecond {
+		t.Errorf("writer interval = %v, want increased; the fresh topo source must drive SetTargetLag, not the stale mysql one", w.interval)
+	}
+}
+
+// TestComputeReplicationLagSkipsFailingSource verifies that a source which
+// errors does not prevent lag from being computed from the remaining
+// sources.
+func TestComputeReplicationLagSkipsFailingSource(t *testing.T) {
+	now := time.Unix(1000, 0)
+	r := &Reader{
+		sources: []HeartbeatSource{
+			&fakeSource{name: mysqlSinkName, err: errTestSourceUnavailable},
+			&fakeSource{name: "topo", ts: now.Add(-2 * time.Second).UnixNano()},
+		},
+	}
+
+	lag, err := r.ComputeReplicationLag(context.Background(), now)
+	if err != nil {
+		t.Fatalf("ComputeReplicationLag() returned error: %v", err)
+	}
+	if lag != 2*time.Second {
+		t.Fatalf("lag = %v, want 2s from the surviving topo source", lag)
+	}
+}
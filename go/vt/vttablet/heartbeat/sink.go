@@ -0,0 +1,161 @@
+/*
+Copyright 2019 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package heartbeat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/net/context"
+
+	"vitess.io/vitess/go/vt/log"
+	"vitess.io/vitess/go/vt/topo"
+	"vitess.io/vitess/go/vt/vttablet/tabletserver/tabletenv"
+)
+
+// HeartbeatSink is a destination a heartbeat tick can be published to, in
+// addition to (or instead of) the _vt.heartbeat row. Each sink reports its
+// own failures independently (see Writer.recordSinkError) so a wedged sink,
+// such as an unreachable webhook, never blocks delivery to the others.
+type HeartbeatSink interface {
+	// Name identifies the sink for logging and the per-sink stats counter.
+	Name() string
+	// Write publishes a single tick for keyspaceShard/tabletUid at ts, the
+	// writer's own clock in nanoseconds.
+	Write(ctx context.Context, keyspaceShard string, tabletUid uint32, ts int64) error
+}
+
+// mysqlSinkName is HeartbeatSink.Name() for mysqlHeartbeatSink. writes and
+// writeErrors (writer.go) are scoped to it by name so those long-standing
+// stats keep their pre-multi-sink meaning of "the _vt.heartbeat row".
+const mysqlSinkName = "mysql"
+
+// newHeartbeatSinks builds the sinks configured for w, in a fixed order
+// with the original MySQL sink always first.
+func newHeartbeatSinks(w *Writer, config *tabletenv.TabletConfig) []HeartbeatSink {
+	sinks := []HeartbeatSink{&mysqlHeartbeatSink{w: w}}
+	for _, name := range config.HeartbeatSinks {
+		switch name {
+		case mysqlSinkName:
+			// Always present; added above.
+		case "topo":
+			sinks = append(sinks, &topoHeartbeatSink{w: w})
+		case "http":
+			if config.HeartbeatHTTPSinkURL == "" {
+				log.Warningf("heartbeat: http sink requested but HeartbeatHTTPSinkURL is unset, skipping")
+				continue
+			}
+			sinks = append(sinks, &httpHeartbeatSink{url: config.HeartbeatHTTPSinkURL, client: &http.Client{}})
+		default:
+			log.Warningf("heartbeat: unknown sink %q, ignoring", name)
+		}
+	}
+	return sinks
+}
+
+// mysqlHeartbeatSink is the original sink: it writes the tick to the
+// _vt.heartbeat row via the tablet's own connection pool.
+type mysqlHeartbeatSink struct {
+	w *Writer
+}
+
+func (s *mysqlHeartbeatSink) Name() string { return mysqlSinkName }
+
+func (s *mysqlHeartbeatSink) Write(ctx context.Context, keyspaceShard string, tabletUid uint32, ts int64) error {
+	update, err := s.w.bindHeartbeatVars(sqlUpdateHeartbeat, ts)
+	if err != nil {
+		return err
+	}
+	return s.w.exec(ctx, s.w.heartbeatVEventComment(ts)+update)
+}
+
+// topoHeartbeatSink publishes the tick to the topo server (Consul, etcd or
+// ZooKeeper, depending on the cluster's configured implementation). This
+// lets operators observe a master's liveness independently of its MySQL: a
+// wedged MySQL behind a healthy vttablet, or vice versa, shows up as a
+// divergence between the mysql and topo sinks.
+type topoHeartbeatSink struct {
+	w *Writer
+}
+
+type topoHeartbeatValue struct {
+	TabletUid uint32 `json:"tablet_uid"`
+	Ts        int64  `json:"ts"`
+}
+
+func (s *topoHeartbeatSink) Name() string { return "topo" }
+
+func (s *topoHeartbeatSink) Write(ctx context.Context, keyspaceShard string, tabletUid uint32, ts int64) error {
+	conn, err := s.w.env.TopoServer().ConnForCell(ctx, topo.GlobalCell)
+	if err != nil {
+		return err
+	}
+	val, err := json.Marshal(topoHeartbeatValue{TabletUid: tabletUid, Ts: ts})
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("heartbeat/%s", keyspaceShard)
+	if _, _, err := conn.Get(ctx, path); err != nil {
+		if !topo.IsErrType(err, topo.NoNode) {
+			return err
+		}
+		_, err = conn.Create(ctx, path, val)
+		return err
+	}
+	_, err = conn.Update(ctx, path, val, nil)
+	return err
+}
+
+// httpHeartbeatSink POSTs the tick as JSON to a configurable webhook so
+// external monitoring can ingest heartbeats without polling every replica's
+// _vt.heartbeat table.
+type httpHeartbeatSink struct {
+	url    string
+	client *http.Client
+}
+
+type httpHeartbeatPayload struct {
+	KeyspaceShard string `json:"keyspace_shard"`
+	TabletUid     uint32 `json:"tablet_uid"`
+	Ts            int64  `json:"ts"`
+}
+
+func (s *httpHeartbeatSink) Name() string { return "http" }
+
+func (s *httpHeartbeatSink) Write(ctx context.Context, keyspaceShard string, tabletUid uint32, ts int64) error {
+	body, err := json.Marshal(httpHeartbeatPayload{KeyspaceShard: keyspaceShard, TabletUid: tabletUid, Ts: ts})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("heartbeat webhook %s returned status %d", s.url, resp.StatusCode)
+	}
+	return nil
+}
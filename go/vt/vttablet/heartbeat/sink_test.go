@@ -0,0 +1,139 @@
+/*
+Copyright 2019 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package heartbeat
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"vitess.io/vitess/go/vt/logutil"
+	"vitess.io/vitess/go/vt/vttablet/tabletserver/tabletenv"
+)
+
+// fakeEnv satisfies tabletenv.Env for writeHeartbeat, which only calls its
+// LogError; any other method would panic on the nil embedded Env.
+type fakeEnv struct {
+	tabletenv.Env
+}
+
+func (fakeEnv) LogError() {}
+
+// fakeSink is a HeartbeatSink double that records every call, optionally
+// blocks until released, and optionally fails.
+type fakeSink struct {
+	name    string
+	fail    error
+	block   chan struct{}
+	mu      sync.Mutex
+	writes  int
+	lastCtx context.Context
+}
+
+func (s *fakeSink) Name() string { return s.name }
+
+func (s *fakeSink) Write(ctx context.Context, keyspaceShard string, tabletUid uint32, ts int64) error {
+	if s.block != nil {
+		<-s.block
+	}
+	s.mu.Lock()
+	s.writes++
+	s.lastCtx = ctx
+	s.mu.Unlock()
+	return s.fail
+}
+
+func (s *fakeSink) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writes
+}
+
+// TestWriteHeartbeatFanOutDoesNotBlockOnSlowSink verifies that a sink which
+// never unblocks does not prevent the other configured sinks from
+// completing their write for the same tick.
+func TestWriteHeartbeatFanOutDoesNotBlockOnSlowSink(t *testing.T) {
+	slow := &fakeSink{name: "slow", block: make(chan struct{})}
+	fast := &fakeSink{name: "fast"}
+
+	w := &Writer{
+		env:           fakeEnv{},
+		enabled:       true,
+		interval:      time.Minute,
+		now:           time.Now,
+		keyspaceShard: "ks:0",
+		sinks:         []HeartbeatSink{slow, fast},
+		errorLog:      logutil.NewThrottledLogger("Test", 60*time.Second),
+	}
+
+	done := make(chan struct{})
+	go func() {
+		w.writeHeartbeat()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("writeHeartbeat returned before the slow sink was released; it must wait for every sink")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if fast.count() != 1 {
+		t.Fatalf("fast sink write count = %d, want 1 (must not be blocked by the slow sink)", fast.count())
+	}
+	close(slow.block)
+	<-done
+	if slow.count() != 1 {
+		t.Fatalf("slow sink write count = %d, want 1", slow.count())
+	}
+}
+
+// TestWriteHeartbeatStatsScopedToMySQLSink verifies that the legacy
+// writes/writeErrors counters only reflect the mysql sink, while every
+// sink's failures are independently observable via sinkErrors.
+func TestWriteHeartbeatStatsScopedToMySQLSink(t *testing.T) {
+	mysql := &fakeSink{name: mysqlSinkName}
+	failing := &fakeSink{name: "http", fail: errors.New("webhook unreachable")}
+
+	w := &Writer{
+		env:           fakeEnv{},
+		enabled:       true,
+		interval:      time.Minute,
+		now:           time.Now,
+		keyspaceShard: "ks:0",
+		sinks:         []HeartbeatSink{mysql, failing},
+		errorLog:      logutil.NewThrottledLogger("Test", 60*time.Second),
+	}
+
+	before := writes.Get()
+	beforeErrs := writeErrors.Get()
+	beforeSinkErrs := sinkErrors.Counts()["http"]
+
+	w.writeHeartbeat()
+
+	if got := writes.Get() - before; got != 1 {
+		t.Errorf("writes counter increased by %d, want 1 (only the mysql sink counts)", got)
+	}
+	if got := writeErrors.Get() - beforeErrs; got != 0 {
+		t.Errorf("writeErrors counter increased by %d, want 0 (http sink failure must not count against it)", got)
+	}
+	if got := sinkErrors.Counts()["http"] - beforeSinkErrs; got != 1 {
+		t.Errorf("sinkErrors[http] increased by %d, want 1", got)
+	}
+}
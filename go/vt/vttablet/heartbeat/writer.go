@@ -18,6 +18,7 @@ package heartbeat
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -26,6 +27,7 @@ import (
 	"golang.org/x/net/context"
 
 	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/stats"
 	"vitess.io/vitess/go/timer"
 	"vitess.io/vitess/go/vt/dbconfigs"
 	"vitess.io/vitess/go/vt/dbconnpool"
@@ -39,6 +41,13 @@ import (
 	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
 )
 
+var (
+	writes              = stats.NewCounter("HeartbeatWrites", "Number of heartbeats written")
+	writeErrors         = stats.NewCounter("HeartbeatWriteErrors", "Number of errors writing heartbeats")
+	sinkErrors          = stats.NewCountersWithSingleLabel("HeartbeatSinkErrors", "Number of errors writing heartbeats, per sink", "sink")
+	heartbeatIntervalMs = stats.NewGauge("HeartbeatIntervalMs", "Current heartbeat interval, in milliseconds")
+)
+
 const (
 	sqlCreateSidecarDB      = "create database if not exists %s"
 	sqlCreateHeartbeatTable = `CREATE TABLE IF NOT EXISTS %s.heartbeat (
@@ -48,6 +57,25 @@ const (
         ) engine=InnoDB`
 	sqlInsertInitialRow = "INSERT INTO %s.heartbeat (ts, tabletUid, keyspaceShard) VALUES (%a, %a, %a) ON DUPLICATE KEY UPDATE ts=VALUES(ts)"
 	sqlUpdateHeartbeat  = "UPDATE %s.heartbeat SET ts=%a, tabletUid=%a WHERE keyspaceShard=%a"
+
+	// heartbeatVEventCommentFormat annotates the heartbeat UPDATE with a
+	// watermark that the vstreamer recognizes and turns into a
+	// VEventType_HEARTBEAT VEvent on every matching stream. ts is the
+	// writer's own monotonic clock (not the binlog event time), so a VStream
+	// consumer can use it to compute end-to-end replication+ingestion lag.
+	// The resulting VEvent must never be dropped by row or table filters,
+	// and must be emitted on every tick, even on shards with no other
+	// writes, so idle consumers can still advance their position.
+	heartbeatVEventCommentFormat = "/*vt+ HEARTBEAT ts=%d uid=%d ks=%s */ "
+
+	// aimdAdditiveStep is the fixed step the AIMD controller subtracts from
+	// the interval, per SetTargetLag call, while observed lag is at or
+	// above threshold.
+	aimdAdditiveStep = 100 * time.Millisecond
+	// aimdMultiplicativeFactor is the factor the AIMD controller multiplies
+	// the interval by, per SetTargetLag call, while observed lag stays
+	// below threshold.
+	aimdMultiplicativeFactor = 1.5
 )
 
 // Writer runs on master tablets and writes heartbeats to the _vt.heartbeat
@@ -56,16 +84,25 @@ type Writer struct {
 	env tabletenv.Env
 
 	enabled       bool
-	interval      time.Duration
+	enableVEvent  bool
+	baseInterval  time.Duration
+	minInterval   time.Duration
+	maxInterval   time.Duration
+	lagThreshold  time.Duration
 	tabletAlias   topodatapb.TabletAlias
 	keyspaceShard string
 	now           func() time.Time
 	errorLog      *logutil.ThrottledLogger
 
-	mu     sync.Mutex
-	isOpen bool
-	pool   *connpool.Pool
-	ticks  *timer.Timer
+	// mu guards interval in addition to the fields below: the AIMD
+	// controller driven by SetTargetLag can reschedule the ticker from a
+	// different goroutine than the one that started it.
+	mu       sync.Mutex
+	interval time.Duration
+	isOpen   bool
+	pool     *connpool.Pool
+	ticks    *timer.Timer
+	sinks    []HeartbeatSink
 }
 
 // NewWriter creates a new Writer.
@@ -75,19 +112,74 @@ func NewWriter(env tabletenv.Env, alias topodatapb.TabletAlias) *Writer {
 		return &Writer{}
 	}
 	heartbeatInterval := time.Duration(config.HeartbeatIntervalSeconds * 1e9)
-	return &Writer{
-		env:         env,
-		enabled:     true,
-		tabletAlias: alias,
-		now:         time.Now,
-		interval:    heartbeatInterval,
-		ticks:       timer.NewTimer(heartbeatInterval),
-		errorLog:    logutil.NewThrottledLogger("HeartbeatWriter", 60*time.Second),
+	w := &Writer{
+		env:          env,
+		enabled:      true,
+		enableVEvent: config.HeartbeatEnableVEvent,
+		baseInterval: heartbeatInterval,
+		minInterval:  time.Duration(config.HeartbeatMinIntervalMs) * time.Millisecond,
+		maxInterval:  time.Duration(config.HeartbeatMaxIntervalMs) * time.Millisecond,
+		lagThreshold: time.Duration(config.HeartbeatLagThresholdSeconds * 1e9),
+		tabletAlias:  alias,
+		now:          time.Now,
+		interval:     heartbeatInterval,
+		ticks:        timer.NewTimer(heartbeatInterval),
+		errorLog:     logutil.NewThrottledLogger("HeartbeatWriter", 60*time.Second),
 		pool: connpool.NewPool(env, "HeartbeatWritePool", tabletenv.ConnPoolConfig{
 			Size:               1,
 			IdleTimeoutSeconds: env.Config().OltpReadPool.IdleTimeoutSeconds,
 		}),
 	}
+	w.sinks = newHeartbeatSinks(w, config)
+	heartbeatIntervalMs.Set(int64(heartbeatInterval / time.Millisecond))
+	if (w.minInterval > 0 || w.maxInterval > 0) && !w.adaptiveEnabled() {
+		log.Warningf("heartbeat: HeartbeatMinIntervalMs (%v) and HeartbeatMaxIntervalMs (%v) must both be set with min <= max; adaptive interval control is disabled", w.minInterval, w.maxInterval)
+	}
+	return w
+}
+
+// adaptiveEnabled reports whether the AIMD interval controller is
+// configured. Both bounds must be set and ordered min <= max; otherwise the
+// writer keeps its fixed, configured interval.
+func (w *Writer) adaptiveEnabled() bool {
+	return w.minInterval > 0 && w.maxInterval > 0 && w.minInterval <= w.maxInterval
+}
+
+// SetTargetLag feeds an observed replica lag sample into the writer's AIMD
+// interval controller. It is called by Reader.ComputeReplicationLag with
+// each lag it computes; Writer has no other visibility into replica state. Lag below lagThreshold backs the interval off
+// multiplicatively, toward HeartbeatMaxIntervalMs, trading resolution for
+// reduced binlog churn on a quiet shard; lag at or above threshold ramps it
+// down additively, toward HeartbeatMinIntervalMs, for higher-resolution
+// measurement. A no-op unless both HeartbeatMinIntervalMs and
+// HeartbeatMaxIntervalMs are configured.
+func (w *Writer) SetTargetLag(lag time.Duration) {
+	if !w.enabled || !w.adaptiveEnabled() {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	next := w.interval
+	if lag < w.lagThreshold {
+		next = time.Duration(float64(next) * aimdMultiplicativeFactor)
+		if next > w.maxInterval {
+			next = w.maxInterval
+		}
+	} else {
+		next -= aimdAdditiveStep
+		if next < w.minInterval {
+			next = w.minInterval
+		}
+	}
+	if next == w.interval {
+		return
+	}
+	w.interval = next
+	if w.isOpen {
+		w.ticks.SetInterval(next)
+	}
+	heartbeatIntervalMs.Set(int64(next / time.Millisecond))
 }
 
 // Init runs at tablet startup and last minute initialization of db settings, and
@@ -143,6 +235,12 @@ func (w *Writer) Close() {
 	}
 	w.ticks.Stop()
 	w.pool.Close()
+	// Reset the AIMD controller so the next Open starts back at the
+	// configured baseline rather than wherever the last session's lag
+	// feedback left it.
+	w.interval = w.baseInterval
+	w.ticks.SetInterval(w.baseInterval)
+	heartbeatIntervalMs.Set(int64(w.baseInterval / time.Millisecond))
 	log.Info("Stopped heartbeat writes.")
 	w.isOpen = false
 }
@@ -165,7 +263,7 @@ func (w *Writer) initializeTables(cp dbconfigs.Connector) error {
 			return vterrors.Wrap(err, "Failed to execute heartbeat init query")
 		}
 	}
-	insert, err := w.bindHeartbeatVars(sqlInsertInitialRow)
+	insert, err := w.bindHeartbeatVars(sqlInsertInitialRow, w.now().UnixNano())
 	if err != nil {
 		return vterrors.Wrap(err, "Failed to bindHeartbeatVars initial heartbeat insert")
 	}
@@ -180,10 +278,10 @@ func (w *Writer) initializeTables(cp dbconfigs.Connector) error {
 // bindHeartbeatVars takes a heartbeat write (insert or update) and
 // adds the necessary fields to the query as bind vars. This is done
 // to protect ourselves against a badly formed keyspace or shard name.
-func (w *Writer) bindHeartbeatVars(query string) (string, error) {
+func (w *Writer) bindHeartbeatVars(query string, ts int64) (string, error) {
 	bindVars := map[string]*querypb.BindVariable{
 		"ks":  sqltypes.StringBindVariable(w.keyspaceShard),
-		"ts":  sqltypes.Int64BindVariable(w.now().UnixNano()),
+		"ts":  sqltypes.Int64BindVariable(ts),
 		"uid": sqltypes.Int64BindVariable(int64(w.tabletAlias.Uid)),
 	}
 	parsed := sqlparser.BuildParsedQuery(query, "_vt", ":ts", ":uid", ":ks")
@@ -194,22 +292,61 @@ func (w *Writer) bindHeartbeatVars(query string) (string, error) {
 	return bound, nil
 }
 
-// writeHeartbeat updates the heartbeat row for this tablet with the current time in nanoseconds.
+// writeHeartbeat fans the current tick out to every configured sink. Sinks
+// run concurrently, each under its own deadline derived from the writer's
+// interval, so one wedged or slow sink (e.g. an unreachable webhook) can
+// never delay or suppress delivery to the others.
 func (w *Writer) writeHeartbeat() {
 	defer w.env.LogError()
-	ctx, cancel := context.WithDeadline(context.Background(), w.now().Add(w.interval))
-	defer cancel()
-	update, err := w.bindHeartbeatVars(sqlUpdateHeartbeat)
-	if err != nil {
-		w.recordError(err)
-		return
+	ts := w.now()
+	w.mu.Lock()
+	interval := w.interval
+	w.mu.Unlock()
+	deadline := ts.Add(interval)
+	keyspaceShard := w.keyspaceShard
+	tabletUid := w.tabletAlias.Uid
+
+	var wg sync.WaitGroup
+	for _, sink := range w.sinks {
+		wg.Add(1)
+		go func(sink HeartbeatSink) {
+			defer wg.Done()
+			ctx, cancel := context.WithDeadline(context.Background(), deadline)
+			defer cancel()
+			if err := sink.Write(ctx, keyspaceShard, tabletUid, ts.UnixNano()); err != nil {
+				w.recordSinkError(sink.Name(), err)
+				return
+			}
+			// writes/writeErrors predate multi-sink support and are kept
+			// scoped to the original _vt.heartbeat row so existing
+			// dashboards and alerts built on "one write per tick" don't
+			// silently start reading N writes once extra sinks are
+			// configured; per-sink success/failure is tracked via
+			// sinkErrors (failures) above and is otherwise observable
+			// through each sink's own backing system.
+			if sink.Name() == mysqlSinkName {
+				writes.Add(1)
+			}
+		}(sink)
 	}
-	err = w.exec(ctx, update)
-	if err != nil {
-		w.recordError(err)
-		return
+	wg.Wait()
+}
+
+// heartbeatVEventComment returns the /*vt+ HEARTBEAT ... */ annotation to
+// prepend to the heartbeat UPDATE, or the empty string if VEvent emission
+// is disabled. See heartbeatVEventCommentFormat for the invariants the
+// vstreamer relies on when translating this into a VEvent. keyspaceShard is
+// stripped of "*/" so it can never close the comment early and splice
+// arbitrary text into the executed statement.
+func (w *Writer) heartbeatVEventComment(ts int64) string {
+	if !w.enableVEvent {
+		return ""
 	}
-	writes.Add(1)
+	ks := w.keyspaceShard
+	for strings.Contains(ks, "*/") {
+		ks = strings.Replace(ks, "*/", "", -1)
+	}
+	return fmt.Sprintf(heartbeatVEventCommentFormat, ts, w.tabletAlias.Uid, ks)
 }
 
 func (w *Writer) exec(ctx context.Context, query string) error {
@@ -229,3 +366,15 @@ func (w *Writer) recordError(err error) {
 	w.errorLog.Errorf("%v", err)
 	writeErrors.Add(1)
 }
+
+// recordSinkError logs and counts a single sink's failure to write a tick.
+// It never affects the other sinks for this tick. writeErrors, like writes,
+// stays scoped to the original mysql sink; every sink's failures are always
+// counted in sinkErrors.
+func (w *Writer) recordSinkError(sink string, err error) {
+	w.errorLog.Errorf("heartbeat sink %s: %v", sink, err)
+	if sink == mysqlSinkName {
+		writeErrors.Add(1)
+	}
+	sinkErrors.Add(sink, 1)
+}
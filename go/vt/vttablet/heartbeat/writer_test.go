@@ -0,0 +1,102 @@
+/*
+Copyright 2019 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package heartbeat
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdaptiveEnabled(t *testing.T) {
+	cases := []struct {
+		name        string
+		min, max    time.Duration
+		wantEnabled bool
+	}{
+		{"both zero", 0, 0, false},
+		{"only min set", 100 * time.Millisecond, 0, false},
+		{"only max set", 0, time.Second, false},
+		{"min greater than max", time.Second, 100 * time.Millisecond, false},
+		{"min equal to max", 500 * time.Millisecond, 500 * time.Millisecond, true},
+		{"min less than max", 100 * time.Millisecond, time.Second, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := &Writer{minInterval: c.min, maxInterval: c.max}
+			if got := w.adaptiveEnabled(); got != c.wantEnabled {
+				t.Errorf("adaptiveEnabled() = %v, want %v", got, c.wantEnabled)
+			}
+		})
+	}
+}
+
+func newAdaptiveWriter(start, min, max, threshold time.Duration) *Writer {
+	return &Writer{
+		enabled:      true,
+		interval:     start,
+		baseInterval: start,
+		minInterval:  min,
+		maxInterval:  max,
+		lagThreshold: threshold,
+		ticks:        nil,
+	}
+}
+
+func TestSetTargetLagNoop(t *testing.T) {
+	w := newAdaptiveWriter(time.Second, 0, 0, time.Second)
+	w.SetTargetLag(10 * time.Second)
+	if w.interval != time.Second {
+		t.Errorf("interval = %v, want unchanged at %v when adaptive control is disabled", w.interval, time.Second)
+	}
+
+	disabled := &Writer{enabled: false, interval: time.Second, minInterval: 100 * time.Millisecond, maxInterval: time.Second, lagThreshold: time.Second}
+	disabled.SetTargetLag(10 * time.Second)
+	if disabled.interval != time.Second {
+		t.Errorf("interval = %v, want unchanged when writer is disabled", disabled.interval)
+	}
+}
+
+func TestSetTargetLagRampsDownAndClampsAtMin(t *testing.T) {
+	w := newAdaptiveWriter(300*time.Millisecond, 100*time.Millisecond, time.Second, time.Second)
+	for i := 0; i < 10; i++ {
+		w.SetTargetLag(5 * time.Second) // at/above threshold: ramp down
+	}
+	if w.interval != w.minInterval {
+		t.Errorf("interval = %v, want clamped at minInterval %v after repeated high-lag samples", w.interval, w.minInterval)
+	}
+}
+
+func TestSetTargetLagBacksOffAndClampsAtMax(t *testing.T) {
+	w := newAdaptiveWriter(300*time.Millisecond, 100*time.Millisecond, time.Second, time.Second)
+	for i := 0; i < 10; i++ {
+		w.SetTargetLag(0) // below threshold: back off
+	}
+	if w.interval != w.maxInterval {
+		t.Errorf("interval = %v, want clamped at maxInterval %v after repeated low-lag samples", w.interval, w.maxInterval)
+	}
+}
+
+func TestSetTargetLagThresholdIsExclusiveOnLowSide(t *testing.T) {
+	// lag == lagThreshold must ramp down (additive), not back off
+	// (multiplicative): SetTargetLag treats "at or above threshold" as
+	// high-lag.
+	w := newAdaptiveWriter(300*time.Millisecond, 100*time.Millisecond, time.Second, time.Second)
+	w.SetTargetLag(time.Second)
+	if w.interval >= 300*time.Millisecond {
+		t.Errorf("interval = %v, want decreased when lag == lagThreshold", w.interval)
+	}
+}
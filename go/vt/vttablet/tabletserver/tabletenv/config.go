@@ -0,0 +1,99 @@
+/*
+Copyright 2019 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tabletenv
+
+import (
+	"flag"
+	"strings"
+
+	"vitess.io/vitess/go/vt/dbconfigs"
+)
+
+// ConnPoolConfig holds the configuration for a tabletserver connection pool.
+type ConnPoolConfig struct {
+	Size               int
+	IdleTimeoutSeconds float64
+}
+
+// TabletConfig contains the subset of the query service configuration
+// consumed by vttablet/heartbeat.
+type TabletConfig struct {
+	DB           *dbconfigs.DBConfigs
+	OltpReadPool ConnPoolConfig
+
+	// HeartbeatIntervalSeconds is the interval, in seconds, at which
+	// heartbeat.Writer writes to _vt.heartbeat. With adaptive interval
+	// control enabled it is the starting interval, which Writer.Close
+	// restores. Zero disables heartbeat writes entirely.
+	HeartbeatIntervalSeconds float64
+
+	// HeartbeatEnableVEvent makes heartbeat.Writer annotate every write with
+	// a /*vt+ HEARTBEAT ... */ comment that vstreamer recognizes and turns
+	// into a VEventType_HEARTBEAT VEvent, so VStream consumers can use it as
+	// a liveness/lag watermark.
+	HeartbeatEnableVEvent bool
+
+	// HeartbeatSinks lists the heartbeat.HeartbeatSink implementations
+	// heartbeat.Writer fans each tick out to, in addition to the mysql
+	// _vt.heartbeat row, which is always included. Recognized values are
+	// "mysql", "topo" and "http"; unknown values are logged and ignored.
+	HeartbeatSinks []string
+
+	// HeartbeatHTTPSinkURL is the webhook heartbeat.Writer POSTs each tick
+	// to when HeartbeatSinks includes "http". Required for that sink; if
+	// unset, the http sink is skipped with a warning.
+	HeartbeatHTTPSinkURL string
+
+	// HeartbeatMinIntervalMs and HeartbeatMaxIntervalMs bound the AIMD
+	// interval controller heartbeat.Writer.SetTargetLag drives from observed
+	// replica lag. Both must be set, with min <= max, for adaptive interval
+	// control to be enabled; otherwise the writer keeps its fixed
+	// HeartbeatIntervalSeconds interval.
+	HeartbeatMinIntervalMs int64
+	HeartbeatMaxIntervalMs int64
+
+	// HeartbeatLagThresholdSeconds is the observed replica lag, in seconds,
+	// at or above which the AIMD controller ramps the interval down
+	// (additively) toward HeartbeatMinIntervalMs; below it, the interval
+	// backs off (multiplicatively) toward HeartbeatMaxIntervalMs.
+	HeartbeatLagThresholdSeconds float64
+}
+
+var (
+	heartbeatIntervalSeconds     = flag.Float64("heartbeat_interval", 1.0, "how frequently to read and write the heartbeat")
+	heartbeatEnableVEvent        = flag.Bool("heartbeat_enable_vevent", false, "annotate heartbeat writes so vstreamer emits a VEventType_HEARTBEAT VEvent on matching streams")
+	heartbeatSinks               = flag.String("heartbeat_sinks", "mysql", "comma-separated list of heartbeat sinks to write to: mysql, topo, http")
+	heartbeatHTTPSinkURL         = flag.String("heartbeat_http_sink_url", "", "webhook URL for the http heartbeat sink, required if heartbeat_sinks includes http")
+	heartbeatMinIntervalMs       = flag.Int64("heartbeat_min_interval_ms", 0, "lower bound of the heartbeat interval for adaptive control; must be set with heartbeat_max_interval_ms to enable it")
+	heartbeatMaxIntervalMs       = flag.Int64("heartbeat_max_interval_ms", 0, "upper bound of the heartbeat interval for adaptive control; must be set with heartbeat_min_interval_ms to enable it")
+	heartbeatLagThresholdSeconds = flag.Float64("heartbeat_lag_threshold", 0, "replica lag, in seconds, at or above which the adaptive heartbeat controller ramps the interval down toward heartbeat_min_interval_ms")
+)
+
+// NewCurrentConfig returns a TabletConfig populated from the registered
+// flags above and the given db connection settings.
+func NewCurrentConfig(db *dbconfigs.DBConfigs) *TabletConfig {
+	return &TabletConfig{
+		DB:                           db,
+		HeartbeatIntervalSeconds:     *heartbeatIntervalSeconds,
+		HeartbeatEnableVEvent:        *heartbeatEnableVEvent,
+		HeartbeatSinks:               strings.Split(*heartbeatSinks, ","),
+		HeartbeatHTTPSinkURL:         *heartbeatHTTPSinkURL,
+		HeartbeatMinIntervalMs:       *heartbeatMinIntervalMs,
+		HeartbeatMaxIntervalMs:       *heartbeatMaxIntervalMs,
+		HeartbeatLagThresholdSeconds: *heartbeatLagThresholdSeconds,
+	}
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2019 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vstreamer
+
+import (
+	"regexp"
+	"strconv"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+)
+
+// heartbeatCommentRE matches the /*vt+ HEARTBEAT ts=... uid=... ks=... */
+// annotation that vttablet/heartbeat.Writer prepends to its _vt.heartbeat
+// UPDATE (see heartbeatVEventCommentFormat in vttablet/heartbeat/writer.go).
+var heartbeatCommentRE = regexp.MustCompile(`^/\*vt\+ HEARTBEAT ts=(\d+) uid=(\d+) ks=([^*]*) \*/`)
+
+// heartbeatVEvent recognizes the heartbeat watermark comment on a query
+// event's SQL text and, if present, returns the VEvent it translates to.
+//
+// (*vstreamer).parseEvent calls this before any row or table filter is
+// applied to the statement, so the heartbeat watermark is always forwarded:
+// on a stream filtered down to tables that never touch _vt.heartbeat, on a
+// keyspace whose sidecar database is itself filtered out, and on a shard
+// that is otherwise idle. This is what lets a VStream consumer use the
+// watermark to compute end-to-end replication+ingestion lag independent of
+// its own filter.
+func heartbeatVEvent(sql string) *binlogdatapb.VEvent {
+	m := heartbeatCommentRE.FindStringSubmatch(sql)
+	if m == nil {
+		return nil
+	}
+	ts, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return nil
+	}
+	return &binlogdatapb.VEvent{
+		Type:      binlogdatapb.VEventType_HEARTBEAT,
+		Timestamp: ts,
+		Shard:     m[3],
+	}
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2019 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vstreamer
+
+import (
+	"testing"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+)
+
+// TestParseEventRecognizesHeartbeat drives a query event carrying the
+// heartbeat watermark comment through the real vstreamer.parseEvent path
+// (not just the heartbeatVEvent regex in isolation) and checks it comes out
+// the other end as a VEventType_HEARTBEAT VEvent.
+func TestParseEventRecognizesHeartbeat(t *testing.T) {
+	vs := &vstreamer{keyspace: "ks0", shard: "-80"}
+	sql := `/*vt+ HEARTBEAT ts=1234567890 uid=42 ks=ks0:-80 */ UPDATE _vt.heartbeat SET ts=1234567890, tabletUid=42 WHERE keyspaceShard='ks0:-80'`
+
+	events := vs.parseEvent(sql)
+
+	if len(events) != 1 {
+		t.Fatalf("parseEvent returned %d events, want 1", len(events))
+	}
+	ev := events[0]
+	if ev.Type != binlogdatapb.VEventType_HEARTBEAT {
+		t.Errorf("Type = %v, want HEARTBEAT", ev.Type)
+	}
+	if ev.Timestamp != 1234567890 {
+		t.Errorf("Timestamp = %d, want 1234567890", ev.Timestamp)
+	}
+	if ev.Keyspace != "ks0" {
+		t.Errorf("Keyspace = %q, want %q (parseEvent must stamp its own keyspace)", ev.Keyspace, "ks0")
+	}
+}
+
+// TestParseEventIgnoresNonHeartbeatQueries verifies that an ordinary query,
+// with no heartbeat comment, does not get misclassified as a heartbeat.
+func TestParseEventIgnoresNonHeartbeatQueries(t *testing.T) {
+	vs := &vstreamer{keyspace: "ks0", shard: "-80"}
+	events := vs.parseEvent(`UPDATE customer SET balance=100 WHERE id=1`)
+
+	if len(events) != 1 {
+		t.Fatalf("parseEvent returned %d events, want 1", len(events))
+	}
+	if events[0].Type == binlogdatapb.VEventType_HEARTBEAT {
+		t.Errorf("an ordinary query must not be classified as HEARTBEAT")
+	}
+}
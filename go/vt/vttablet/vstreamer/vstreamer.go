@@ -0,0 +1,41 @@
+/*
+Copyright 2019 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vstreamer
+
+import (
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+)
+
+// vstreamer streams binlog events for a single VStream request, translating
+// each query event's SQL text into the VEvents delivered to the subscriber.
+type vstreamer struct {
+	keyspace string
+	shard    string
+}
+
+// parseEvent is the entry point every query event in the binlog stream is
+// run through, before any row or table filter is applied to it. It is where
+// heartbeatVEvent is wired in: a heartbeat watermark must reach every
+// subscriber regardless of their filter, so it is recognized and returned
+// here first, ahead of statement-based filtering.
+func (vs *vstreamer) parseEvent(sql string) []*binlogdatapb.VEvent {
+	if ev := heartbeatVEvent(sql); ev != nil {
+		ev.Keyspace = vs.keyspace
+		return []*binlogdatapb.VEvent{ev}
+	}
+	return []*binlogdatapb.VEvent{{Type: binlogdatapb.VEventType_OTHER}}
+}